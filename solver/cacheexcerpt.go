@@ -0,0 +1,357 @@
+package solver
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultExcerptCapacity bounds how many cache keys' link/result fanout is
+// kept in the excerpt index at once. It is deliberately generous: an entry
+// is just a handful of small maps, and eviction here only costs a backend
+// round-trip on the next miss, never correctness.
+const defaultExcerptCapacity = 4096
+
+// cacheExcerpt is a RAM-resident summary of a single cache key's link and
+// result fanout, used to avoid re-walking backend.WalkLinks/WalkResults on
+// every query. It is populated lazily: entries only exist for the
+// (parentID, CacheInfoLink) and result combinations a caller has actually
+// looked up or added.
+type cacheExcerpt struct {
+	mu      sync.Mutex
+	links   map[CacheInfoLink]map[string]struct{}
+	results map[string]time.Time
+	// resultsLoaded distinguishes "results is the complete, backend-verified
+	// set for this id" from "results holds whatever individual results
+	// Save/WarmExcerpts happened to record so far". Records only trusts
+	// results (skipping backend.WalkResults) once this is true.
+	resultsLoaded bool
+}
+
+func newCacheExcerpt() *cacheExcerpt {
+	return &cacheExcerpt{
+		links:   map[CacheInfoLink]map[string]struct{}{},
+		results: map[string]time.Time{},
+	}
+}
+
+// excerptStore is the cacheManager-wide holder of cacheExcerpts, keyed by
+// cache key id, plus a small side index from root digest to cache key id.
+// Both are LRUs bounded by the capacity given to newExcerptStore; a capacity
+// of 0 leaves them unbounded.
+type excerptStore struct {
+	entries *excerptLRU
+	roots   *rootLRU
+}
+
+func newExcerptStore(capacity int) *excerptStore {
+	return &excerptStore{
+		entries: newExcerptLRU(capacity),
+		roots:   newRootLRU(capacity),
+	}
+}
+
+func (es *excerptStore) entry(id string) *cacheExcerpt {
+	if e, ok := es.entries.get(id); ok {
+		return e
+	}
+	e := newCacheExcerpt()
+	es.entries.set(id, e)
+	return e
+}
+
+// lookupLinks returns a copy of the cached set of ids linked from parentID
+// via l, and whether that set has actually been populated (as opposed to
+// merely having an excerpt entry for unrelated links). A copy is returned
+// rather than the live map so callers can range over the result after
+// releasing e.mu without racing recordLink/storeLinks.
+func (es *excerptStore) lookupLinks(parentID string, l CacheInfoLink) (map[string]struct{}, bool) {
+	e, ok := es.entries.get(parentID)
+	if !ok {
+		return nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids, ok := e.links[l]
+	if !ok {
+		return nil, false
+	}
+	cp := make(map[string]struct{}, len(ids))
+	for id := range ids {
+		cp[id] = struct{}{}
+	}
+	return cp, true
+}
+
+// storeLinks records the full set of ids linked from parentID via l, as
+// just read from the backend.
+func (es *excerptStore) storeLinks(parentID string, l CacheInfoLink, ids map[string]struct{}) {
+	e := es.entry(parentID)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.links[l] = ids
+}
+
+// recordLink adds a single newly created link to an already-populated
+// excerpt for parentID. It is intentionally a no-op when the set for
+// (parentID, l) hasn't been populated yet: inventing a partial entry here
+// would make the next lookupLinks believe it already holds the complete set.
+func (es *excerptStore) recordLink(parentID string, l CacheInfoLink, targetID string) {
+	e, ok := es.entries.get(parentID)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids, ok := e.links[l]
+	if !ok {
+		return
+	}
+	ids[targetID] = struct{}{}
+}
+
+// lookupResults returns a copy of the cached result set for id, and whether
+// it has actually been fully populated from the backend (as opposed to
+// holding only the results individually recorded via recordResult so far).
+// A copy is returned rather than the live map so callers can range over the
+// result after releasing e.mu without racing recordResult/storeResults.
+func (es *excerptStore) lookupResults(id string) (map[string]time.Time, bool) {
+	e, ok := es.entries.get(id)
+	if !ok {
+		return nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.resultsLoaded {
+		return nil, false
+	}
+	cp := make(map[string]time.Time, len(e.results))
+	for resultID, createdAt := range e.results {
+		cp[resultID] = createdAt
+	}
+	return cp, true
+}
+
+// storeResults records the full, backend-verified result set for id, as just
+// read via backend.WalkResults.
+func (es *excerptStore) storeResults(id string, results map[string]time.Time) {
+	e := es.entry(id)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results = results
+	e.resultsLoaded = true
+}
+
+// recordResult adds a single newly saved result to an already-populated
+// excerpt for id. Like recordLink, it is intentionally a no-op when id's
+// results haven't been loaded yet: inventing a partial set here would make
+// the next lookupResults believe it already holds the complete set.
+func (es *excerptStore) recordResult(id, resultID string, createdAt time.Time) {
+	e, ok := es.entries.get(id)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.resultsLoaded {
+		return
+	}
+	e.results[resultID] = createdAt
+}
+
+// invalidate drops the entire excerpt for id, forcing it to be rebuilt from
+// the backend on next access. Used whenever id's backend state changes in a
+// way the excerpt can't cheaply patch up, such as a result release.
+func (es *excerptStore) invalidate(id string) {
+	es.entries.delete(id)
+}
+
+func (es *excerptStore) rootGet(digest string) (string, bool) {
+	return es.roots.get(digest)
+}
+
+func (es *excerptStore) rootSet(digest, id string) {
+	es.roots.set(digest, id)
+}
+
+// rootInvalidate drops the cached root-key existence entry for digest. Used
+// whenever the id it resolves to is released, so a released root key isn't
+// reported as existing indefinitely.
+func (es *excerptStore) rootInvalidate(digest string) {
+	es.roots.delete(digest)
+}
+
+// LinkWalker is implemented by CacheKeyStorage backends that support
+// enumerating every outgoing link recorded for an id, regardless of its
+// CacheInfoLink filter. WarmExcerpts uses it to warm link fanout, which is
+// the expensive part of Query that an excerpt actually saves - the plain
+// backend.WalkLinks used elsewhere requires a specific CacheInfoLink and so
+// can't be used to warm "all links for id" in one pass.
+type LinkWalker interface {
+	WalkAllLinks(id string, f func(l CacheInfoLink, target string) error) error
+}
+
+// ExcerptWarmer is implemented by CacheManagers that support prefetching
+// their excerpt index. CacheManager itself stays backend-agnostic, so
+// callers that want to warm excerpts - typically an exporter or importer
+// about to walk a build graph - type-assert to this interface rather than
+// requiring every CacheManager implementation to provide it:
+//
+//	if w, ok := cm.(solver.ExcerptWarmer); ok {
+//		w.WarmExcerpts(ctx, ids)
+//	}
+type ExcerptWarmer interface {
+	WarmExcerpts(ctx context.Context, ids []string) error
+}
+
+// WarmExcerpts eagerly populates the result and link excerpts for the given
+// cache key ids by reading through the backend once, so that callers about
+// to do a lot of cache lookups against them (typically an exporter or
+// importer walking a build graph) don't each pay a cold backend round-trip.
+// Link fanout, the expensive part of Query, is only warmed when the backend
+// implements LinkWalker.
+func (c *cacheManager) WarmExcerpts(ctx context.Context, ids []string) error {
+	linkWalker, hasLinkWalker := c.backend.(LinkWalker)
+
+	for _, id := range ids {
+		results := map[string]time.Time{}
+		if err := c.backend.WalkResults(id, func(r CacheResult) error {
+			results[r.ID] = r.CreatedAt
+			return nil
+		}); err != nil {
+			return err
+		}
+		c.excerpts.storeResults(id, results)
+
+		if !hasLinkWalker {
+			continue
+		}
+		links := map[CacheInfoLink]map[string]struct{}{}
+		if err := linkWalker.WalkAllLinks(id, func(l CacheInfoLink, target string) error {
+			ids, ok := links[l]
+			if !ok {
+				ids = map[string]struct{}{}
+				links[l] = ids
+			}
+			ids[target] = struct{}{}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for l, targets := range links {
+			c.excerpts.storeLinks(id, l, targets)
+		}
+	}
+	return nil
+}
+
+// excerptLRU and rootLRU are small fixed-capacity LRUs. They're kept
+// separate (rather than a single generic type) to avoid the indirection of
+// a type parameter for what are, in practice, exactly two instantiations.
+
+type excerptLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ls       *list.List
+	items    map[string]*list.Element
+}
+
+type excerptLRUEntry struct {
+	key   string
+	value *cacheExcerpt
+}
+
+func newExcerptLRU(capacity int) *excerptLRU {
+	return &excerptLRU{capacity: capacity, ls: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *excerptLRU) get(key string) (*cacheExcerpt, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ls.MoveToFront(el)
+	return el.Value.(*excerptLRUEntry).value, true
+}
+
+func (l *excerptLRU) set(key string, value *cacheExcerpt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		el.Value.(*excerptLRUEntry).value = value
+		l.ls.MoveToFront(el)
+		return
+	}
+	el := l.ls.PushFront(&excerptLRUEntry{key: key, value: value})
+	l.items[key] = el
+	if l.capacity > 0 && l.ls.Len() > l.capacity {
+		back := l.ls.Back()
+		l.ls.Remove(back)
+		delete(l.items, back.Value.(*excerptLRUEntry).key)
+	}
+}
+
+func (l *excerptLRU) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.ls.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+type rootLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ls       *list.List
+	items    map[string]*list.Element
+}
+
+type rootLRUEntry struct {
+	key   string
+	value string
+}
+
+func newRootLRU(capacity int) *rootLRU {
+	return &rootLRU{capacity: capacity, ls: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *rootLRU) get(key string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return "", false
+	}
+	l.ls.MoveToFront(el)
+	return el.Value.(*rootLRUEntry).value, true
+}
+
+func (l *rootLRU) set(key, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		el.Value.(*rootLRUEntry).value = value
+		l.ls.MoveToFront(el)
+		return
+	}
+	el := l.ls.PushFront(&rootLRUEntry{key: key, value: value})
+	l.items[key] = el
+	if l.capacity > 0 && l.ls.Len() > l.capacity {
+		back := l.ls.Back()
+		l.ls.Remove(back)
+		delete(l.items, back.Value.(*rootLRUEntry).key)
+	}
+}
+
+func (l *rootLRU) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.ls.Remove(el)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,119 @@
+package solver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExcerptStoreLinksRoundTrip(t *testing.T) {
+	es := newExcerptStore(0)
+	l := CacheInfoLink{}
+
+	if _, ok := es.lookupLinks("parent", l); ok {
+		t.Fatalf("expected no cached links before storeLinks")
+	}
+
+	es.storeLinks("parent", l, map[string]struct{}{"a": {}})
+	ids, ok := es.lookupLinks("parent", l)
+	if !ok || len(ids) != 1 {
+		t.Fatalf("expected stored links to be retrievable, got %v, %v", ids, ok)
+	}
+
+	// recordLink is a no-op for an id with no excerpt yet - inventing a
+	// partial entry would make lookupLinks believe it holds the full set.
+	es.recordLink("untouched", l, "x")
+	if _, ok := es.lookupLinks("untouched", l); ok {
+		t.Fatalf("recordLink should not create a populated entry for an unknown id")
+	}
+
+	es.recordLink("parent", l, "b")
+	ids, ok = es.lookupLinks("parent", l)
+	if !ok || len(ids) != 2 {
+		t.Fatalf("expected recordLink to extend an already-populated set, got %v", ids)
+	}
+}
+
+func TestExcerptStoreResultsLoadedSemantics(t *testing.T) {
+	es := newExcerptStore(0)
+
+	// recordResult before any results have been loaded must not fabricate a
+	// "complete" set - Records would otherwise trust a partial result list.
+	es.recordResult("id", "r1", time.Time{})
+	if _, ok := es.lookupResults("id"); ok {
+		t.Fatalf("expected recordResult to be a no-op before storeResults")
+	}
+
+	es.storeResults("id", map[string]time.Time{"r1": time.Time{}})
+	results, ok := es.lookupResults("id")
+	if !ok || len(results) != 1 {
+		t.Fatalf("expected storeResults to populate a complete, loaded set")
+	}
+
+	es.recordResult("id", "r2", time.Time{})
+	results, ok = es.lookupResults("id")
+	if !ok || len(results) != 2 {
+		t.Fatalf("expected recordResult to extend an already-loaded set, got %v", results)
+	}
+}
+
+func TestExcerptStoreInvalidate(t *testing.T) {
+	es := newExcerptStore(0)
+	l := CacheInfoLink{}
+
+	es.storeLinks("id", l, map[string]struct{}{"a": {}})
+	es.storeResults("id", map[string]time.Time{"r1": time.Time{}})
+
+	es.invalidate("id")
+
+	if _, ok := es.lookupLinks("id", l); ok {
+		t.Fatalf("expected invalidate to drop cached links")
+	}
+	if _, ok := es.lookupResults("id"); ok {
+		t.Fatalf("expected invalidate to drop cached results")
+	}
+}
+
+func TestExcerptStoreRootInvalidate(t *testing.T) {
+	es := newExcerptStore(0)
+
+	es.rootSet("digest", "id")
+	if _, ok := es.rootGet("digest"); !ok {
+		t.Fatalf("expected rootSet to populate rootGet")
+	}
+
+	es.rootInvalidate("digest")
+	if _, ok := es.rootGet("digest"); ok {
+		t.Fatalf("expected rootInvalidate to drop the cached root entry")
+	}
+}
+
+func TestExcerptLRUCapacityEviction(t *testing.T) {
+	l := newExcerptLRU(2)
+	l.set("a", newCacheExcerpt())
+	l.set("b", newCacheExcerpt())
+	l.set("c", newCacheExcerpt())
+
+	if _, ok := l.get("a"); ok {
+		t.Fatalf("expected least-recently-used entry a to be evicted at capacity")
+	}
+	if _, ok := l.get("b"); !ok {
+		t.Fatalf("expected b to still be present")
+	}
+	if _, ok := l.get("c"); !ok {
+		t.Fatalf("expected c to still be present")
+	}
+}
+
+func TestRootLRUCapacityEviction(t *testing.T) {
+	l := newRootLRU(2)
+	l.set("a", "1")
+	l.set("b", "2")
+	l.set("c", "3")
+
+	if _, ok := l.get("a"); ok {
+		t.Fatalf("expected least-recently-used root entry a to be evicted at capacity")
+	}
+	if v, ok := l.get("c"); !ok || v != "3" {
+		t.Fatalf("expected c to still be present with its value, got %v, %v", v, ok)
+	}
+}
@@ -20,11 +20,19 @@ func NewInMemoryCacheManager() CacheManager {
 }
 
 // NewCacheManager creates a new cache manager with specific storage backend
-func NewCacheManager(ctx context.Context, id string, storage CacheKeyStorage, results CacheResultStorage) CacheManager {
+func NewCacheManager(ctx context.Context, id string, storage CacheKeyStorage, results CacheResultStorage, opts ...CacheManagerOpt) CacheManager {
 	cm := &cacheManager{
-		id:      id,
-		backend: storage,
-		results: results,
+		id:       id,
+		backend:  storage,
+		results:  results,
+		excerpts: newExcerptStore(defaultExcerptCapacity),
+	}
+
+	for _, opt := range opts {
+		opt(cm)
+	}
+	if cm.observer == nil {
+		cm.observer = noopObserver{}
 	}
 
 	if err := cm.ReleaseUnreferenced(ctx); err != nil {
@@ -40,6 +48,21 @@ type cacheManager struct {
 
 	backend CacheKeyStorage
 	results CacheResultStorage
+
+	// evictor is non-nil for cache managers constructed with a size or
+	// entry-count budget (see NewInMemoryCacheManagerWithOptions). It is
+	// left nil for unbounded cache managers so the extra bookkeeping below
+	// is skipped entirely.
+	evictor *cacheEvictor
+
+	// excerpts is a RAM-resident summary of link and result fanout, used to
+	// avoid re-walking the backend's link graph on every query. See
+	// cacheexcerpt.go.
+	excerpts *excerptStore
+
+	// observer receives activity notifications for metrics/observability.
+	// Always non-nil; defaults to a no-op.
+	observer CacheManagerObserver
 }
 
 func (c *cacheManager) ReleaseUnreferenced(ctx context.Context) error {
@@ -51,7 +74,7 @@ func (c *cacheManager) ReleaseUnreferenced(ctx context.Context) error {
 			}
 			visited[cr.ID] = struct{}{}
 			if !c.results.Exists(ctx, cr.ID) {
-				c.backend.Release(cr.ID)
+				c.release(id, cr.ID)
 			}
 			return nil
 		})
@@ -76,12 +99,14 @@ func (c *cacheManager) Query(deps []CacheKeyWithSelector, input Index, dgst dige
 		"output":        output,
 		"stack":         bklog.TraceLevelOnlyStack(),
 	})
+	start := time.Now()
 	defer func() {
 		rcksField := make([]map[string]any, len(rcks))
 		for i, rck := range rcks {
 			rcksField[i] = rck.TraceFields()
 		}
 		lg.WithError(rerr).WithField("return_cachekeys", rcksField).Trace("cache manager")
+		c.observer.OnQuery(len(rcks) > 0, len(deps), time.Since(start))
 	}()
 
 	c.mu.RLock()
@@ -99,11 +124,12 @@ func (c *cacheManager) Query(deps []CacheKeyWithSelector, input Index, dgst dige
 
 	allRes := map[string]*CacheKey{}
 	for _, d := range allDeps {
-		if err := c.backend.WalkLinks(c.getID(d.key.CacheKey.CacheKey), CacheInfoLink{input, output, dgst, d.key.Selector}, func(id string) error {
+		if err := c.walkLinks(c.getID(d.key.CacheKey.CacheKey), CacheInfoLink{input, output, dgst, d.key.Selector}, func(id string) error {
 			d.results[id] = struct{}{}
 			if _, ok := allRes[id]; !ok {
 				allRes[id] = c.newKeyWithID(id, dgst, output)
 			}
+			c.touch(id)
 			return nil
 		}); err != nil {
 			return nil, err
@@ -114,7 +140,7 @@ func (c *cacheManager) Query(deps []CacheKeyWithSelector, input Index, dgst dige
 	for id, key := range allRes {
 		for _, d := range allDeps {
 			if _, ok := d.results[id]; !ok {
-				if err := c.backend.AddLink(c.getID(d.key.CacheKey.CacheKey), CacheInfoLink{
+				if err := c.addLink(c.getID(d.key.CacheKey.CacheKey), CacheInfoLink{
 					Input:    input,
 					Output:   output,
 					Digest:   dgst,
@@ -127,9 +153,14 @@ func (c *cacheManager) Query(deps []CacheKeyWithSelector, input Index, dgst dige
 	}
 
 	if len(deps) == 0 {
-		if !c.backend.Exists(rootKey(dgst, output).String()) {
-			return nil, nil
+		rk := rootKey(dgst, output).String()
+		if _, ok := c.excerpts.rootGet(rk); !ok {
+			if !c.backend.Exists(rk) {
+				return nil, nil
+			}
+			c.excerpts.rootSet(rk, rk)
 		}
+		c.touch(rk)
 		return []*CacheKey{c.newRootKey(dgst, output)}, nil
 	}
 
@@ -147,30 +178,44 @@ func (c *cacheManager) Records(ctx context.Context, ck *CacheKey) (rrecs []*Cach
 		"cachekey":      ck.TraceFields(),
 		"stack":         bklog.TraceLevelOnlyStack(),
 	})
+	start := time.Now()
 	defer func() {
 		rrercsField := make([]map[string]any, len(rrecs))
 		for i, rrec := range rrecs {
 			rrercsField[i] = rrec.TraceFields()
 		}
 		lg.WithError(rerr).WithField("return_records", rrercsField).Trace("cache manager")
+		c.observer.OnQuery(rerr == nil && len(rrecs) > 0, 1, time.Since(start))
 	}()
 
-	outs := make([]*CacheRecord, 0)
-	if err := c.backend.WalkResults(c.getID(ck), func(r CacheResult) error {
-		if c.results.Exists(ctx, r.ID) {
+	id := c.getID(ck)
+
+	results, ok := c.excerpts.lookupResults(id)
+	if !ok {
+		results = map[string]time.Time{}
+		if err := c.backend.WalkResults(id, func(r CacheResult) error {
+			results[r.ID] = r.CreatedAt
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		c.excerpts.storeResults(id, results)
+	}
+
+	outs := make([]*CacheRecord, 0, len(results))
+	for resultID, createdAt := range results {
+		if c.results.Exists(ctx, resultID) {
 			outs = append(outs, &CacheRecord{
-				ID:           r.ID,
+				ID:           resultID,
 				cacheManager: c,
 				key:          ck,
-				CreatedAt:    r.CreatedAt,
+				CreatedAt:    createdAt,
 			})
 		} else {
-			c.backend.Release(r.ID)
+			c.release(id, resultID)
 		}
-		return nil
-	}); err != nil {
-		return nil, err
 	}
+	c.touch(id)
 	return outs, nil
 }
 
@@ -181,12 +226,18 @@ func (c *cacheManager) Load(ctx context.Context, rec *CacheRecord) (rres Result,
 		"record":        rec.TraceFields(),
 		"stack":         bklog.TraceLevelOnlyStack(),
 	})
+	start := time.Now()
 	defer func() {
 		rresID := "<nil>"
+		var bytes int64
 		if rres != nil {
 			rresID = rres.ID()
+			if s, ok := c.results.(Sizer); ok {
+				bytes, _ = s.Size(rresID)
+			}
 		}
 		lg.WithError(rerr).WithField("return_result", rresID).Trace("cache manager")
+		c.observer.OnLoad(rerr == nil && rres != nil, bytes, time.Since(start))
 	}()
 
 	c.mu.RLock()
@@ -197,6 +248,8 @@ func (c *cacheManager) Load(ctx context.Context, rec *CacheRecord) (rres Result,
 		return nil, err
 	}
 
+	c.touch(c.getID(rec.key))
+
 	return c.results.Load(ctx, res)
 }
 
@@ -258,18 +311,33 @@ func (c *cacheManager) LoadWithParents(ctx context.Context, rec *CacheRecord) (r
 		"record":        rec.TraceFields(),
 		"stack":         bklog.TraceLevelOnlyStack(),
 	})
+	start := time.Now()
+	observe := true
 	defer func() {
 		rresField := make([]map[string]any, len(rres))
 		for i, rres := range rres {
 			rresField[i] = rres.TraceFields()
 		}
 		lg.WithError(rerr).WithField("return_results", rresField).Trace("cache manager")
+		if !observe {
+			// c.Load already reported this via its own OnLoad call below.
+			return
+		}
+		var bytes int64
+		if s, ok := c.results.(Sizer); ok {
+			for _, r := range rres {
+				b, _ := s.Size(r.CacheResult.ID)
+				bytes += b
+			}
+		}
+		c.observer.OnLoad(rerr == nil && len(rres) > 0, bytes, time.Since(start))
 	}()
 
 	lwp, ok := c.results.(interface {
 		LoadWithParents(context.Context, CacheResult) (map[string]Result, error)
 	})
 	if !ok {
+		observe = false
 		res, err := c.Load(ctx, rec)
 		if err != nil {
 			return nil, err
@@ -284,6 +352,8 @@ func (c *cacheManager) LoadWithParents(ctx context.Context, rec *CacheRecord) (r
 		return nil, err
 	}
 
+	c.touch(c.getID(rec.key))
+
 	m, err := lwp.LoadWithParents(ctx, cr)
 	if err != nil {
 		return nil, err
@@ -335,6 +405,15 @@ func (c *cacheManager) Save(k *CacheKey, r Result, createdAt time.Time) (rck *Ex
 		return nil, err
 	}
 
+	c.recordInsert(c.getID(k), res.ID)
+	c.excerpts.recordResult(c.getID(k), res.ID, res.CreatedAt)
+
+	var bytes int64
+	if s, ok := c.results.(Sizer); ok {
+		bytes, _ = s.Size(res.ID)
+	}
+	c.observer.OnSave(bytes)
+
 	rec := &CacheRecord{
 		ID:           res.ID,
 		cacheManager: c,
@@ -394,11 +473,11 @@ func (c *cacheManager) ensurePersistentKey(k *CacheKey) error {
 				Selector: ck.Selector,
 			}
 			ckID := c.getID(ck.CacheKey.CacheKey)
-			if !c.backend.HasLink(ckID, l, id) {
+			if !c.hasLink(ckID, l, id) {
 				if err := c.ensurePersistentKey(ck.CacheKey.CacheKey); err != nil {
 					return err
 				}
-				if err := c.backend.AddLink(ckID, l, id); err != nil {
+				if err := c.addLink(ckID, l, id); err != nil {
 					return err
 				}
 			}
@@ -414,7 +493,7 @@ func (c *cacheManager) getIDFromDeps(k *CacheKey) string {
 		if i == 0 || len(matches) > 0 {
 			for _, ck := range deps {
 				m2 := make(map[string]struct{})
-				if err := c.backend.WalkLinks(c.getID(ck.CacheKey.CacheKey), CacheInfoLink{
+				if err := c.walkLinks(c.getID(ck.CacheKey.CacheKey), CacheInfoLink{
 					Input:    Index(i),
 					Output:   k.Output(),
 					Digest:   k.Digest(),
@@ -448,6 +527,107 @@ func (c *cacheManager) getIDFromDeps(k *CacheKey) string {
 	return identity.NewID()
 }
 
+// touch records recency/frequency information for id against the configured
+// eviction policy, if any, and forwards to the backend's own Touch hook when
+// it implements one. It is a no-op for unbounded cache managers.
+func (c *cacheManager) touch(id string) {
+	if c.evictor != nil {
+		c.evictor.touch(id)
+	}
+	if t, ok := c.backend.(Toucher); ok {
+		if err := t.Touch(id); err != nil {
+			bklog.G(context.TODO()).Debugf("cache manager %s: touch %s: %v", c.id, id, err)
+		}
+	}
+}
+
+// addLink is a thin wrapper around backend.AddLink that additionally lets the
+// configured evictor, if any, track that targetID is referenced from parentID
+// so it isn't evicted out from under a still-referenced child, and keeps the
+// excerpt index for parentID up to date if it is already populated.
+func (c *cacheManager) addLink(parentID string, l CacheInfoLink, targetID string) error {
+	if err := c.backend.AddLink(parentID, l, targetID); err != nil {
+		return err
+	}
+	if c.evictor != nil {
+		c.evictor.link(parentID, targetID)
+	}
+	c.excerpts.recordLink(parentID, l, targetID)
+	return nil
+}
+
+// hasLink reports whether backend.AddLink(parentID, l, targetID) has already
+// been recorded, consulting the excerpt index before falling through to the
+// backend.
+func (c *cacheManager) hasLink(parentID string, l CacheInfoLink, targetID string) bool {
+	if ids, ok := c.excerpts.lookupLinks(parentID, l); ok {
+		_, has := ids[targetID]
+		return has
+	}
+	return c.backend.HasLink(parentID, l, targetID)
+}
+
+// walkLinks calls f for every id linked from parentID via l, consulting the
+// excerpt index first and falling through to, then populating from, the
+// backend on a miss.
+func (c *cacheManager) walkLinks(parentID string, l CacheInfoLink, f func(id string) error) error {
+	if ids, ok := c.excerpts.lookupLinks(parentID, l); ok {
+		for id := range ids {
+			if err := f(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	found := map[string]struct{}{}
+	if err := c.backend.WalkLinks(parentID, l, func(id string) error {
+		found[id] = struct{}{}
+		return f(id)
+	}); err != nil {
+		return err
+	}
+	c.excerpts.storeLinks(parentID, l, found)
+	return nil
+}
+
+// release releases a backend result owned by parentID and drops any excerpt
+// cached for parentID, including its root-key entry, since a released id's
+// link/result fanout may no longer reflect the backend's state on next
+// read. It also forgets parentID from the configured evictor, if any, so an
+// explicit release doesn't leave it in the evictor's bookkeeping forever,
+// skewing its size/entry accounting against entries that are already gone
+// from the backend.
+func (c *cacheManager) release(parentID, resultID string) {
+	c.backend.Release(resultID)
+	c.excerpts.invalidate(parentID)
+	c.excerpts.rootInvalidate(parentID)
+	if c.evictor != nil {
+		c.evictor.forget(parentID)
+	}
+	c.observer.OnEvict("unreferenced", resultID)
+}
+
+// recordInsert registers a newly saved result with the configured evictor,
+// sizing it via the backend/result storage's Sizer hook when available, and
+// evicts the least valuable entries if doing so pushed past a configured
+// budget. It is a no-op for unbounded cache managers.
+func (c *cacheManager) recordInsert(id string, resultID string) {
+	if c.evictor == nil {
+		return
+	}
+	var sz int64
+	if s, ok := c.backend.(Sizer); ok {
+		if v, err := s.Size(id); err == nil {
+			sz = v
+		}
+	} else if s, ok := c.results.(Sizer); ok {
+		if v, err := s.Size(resultID); err == nil {
+			sz = v
+		}
+	}
+	c.evictor.insert(id, sz)
+}
+
 func rootKey(dgst digest.Digest, output Index) digest.Digest {
 	out, _ := cachedigest.FromBytes(fmt.Appendf(nil, "%s@%d", dgst, output), cachedigest.TypeString)
 	if strings.HasPrefix(dgst.String(), "random:") {
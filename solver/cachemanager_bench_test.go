@@ -0,0 +1,146 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// benchBackend is a minimal in-memory CacheKeyStorage/CacheResultStorage
+// pair, sized for BenchmarkQuery's 10k keys / 100k links fixture. It only
+// implements the methods cacheManager actually calls.
+type benchBackend struct {
+	links map[string]map[CacheInfoLink]map[string]struct{}
+}
+
+func newBenchBackend() *benchBackend {
+	return &benchBackend{links: map[string]map[CacheInfoLink]map[string]struct{}{}}
+}
+
+func (b *benchBackend) Exists(id string) bool { return true }
+
+func (b *benchBackend) Walk(f func(id string) error) error {
+	for id := range b.links {
+		if err := f(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *benchBackend) WalkResults(id string, f func(CacheResult) error) error { return nil }
+
+func (b *benchBackend) Load(id, resultID string) (CacheResult, error) {
+	return CacheResult{ID: resultID}, nil
+}
+
+func (b *benchBackend) AddResult(id string, res CacheResult) error { return nil }
+
+func (b *benchBackend) Release(id string) error { return nil }
+
+func (b *benchBackend) AddLink(id string, l CacheInfoLink, target string) error {
+	m, ok := b.links[id]
+	if !ok {
+		m = map[CacheInfoLink]map[string]struct{}{}
+		b.links[id] = m
+	}
+	ids, ok := m[l]
+	if !ok {
+		ids = map[string]struct{}{}
+		m[l] = ids
+	}
+	ids[target] = struct{}{}
+	return nil
+}
+
+func (b *benchBackend) HasLink(id string, l CacheInfoLink, target string) bool {
+	_, ok := b.links[id][l][target]
+	return ok
+}
+
+func (b *benchBackend) WalkLinks(id string, l CacheInfoLink, f func(id string) error) error {
+	for target := range b.links[id][l] {
+		if err := f(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type benchResultStorage struct{}
+
+func (benchResultStorage) Exists(ctx context.Context, id string) bool { return true }
+func (benchResultStorage) Load(ctx context.Context, res CacheResult) (Result, error) {
+	return nil, nil
+}
+func (benchResultStorage) Save(r Result, createdAt time.Time) (CacheResult, error) {
+	return CacheResult{}, nil
+}
+
+// buildBenchFixture builds 10k parent keys with 10 links each (100k links total),
+// matching the scale the request asked the benchmark to cover.
+const (
+	benchKeys        = 10_000
+	benchLinksPerKey = 10
+)
+
+func buildBenchFixture(b *testing.B) (*cacheManager, []CacheKeyWithSelector, Index, digest.Digest, Index) {
+	backend := newBenchBackend()
+	// Size the excerpt index to hold every parent key, so BenchmarkQueryWarm's
+	// cycle over all of them stays resident instead of thrashing the LRU.
+	cm := NewCacheManager(context.Background(), "bench", backend, benchResultStorage{}, WithExcerptCapacity(benchKeys)).(*cacheManager)
+
+	input, output := Index(0), Index(0)
+	dgst := digest.FromString("bench")
+
+	deps := make([]CacheKeyWithSelector, benchKeys)
+	for i := 0; i < benchKeys; i++ {
+		parent := cm.newKeyWithID(fmt.Sprintf("parent-%d", i), dgst, output)
+		deps[i] = CacheKeyWithSelector{CacheKey: ExportableCacheKey{CacheKey: parent}}
+
+		l := CacheInfoLink{Input: input, Output: output, Digest: dgst, Selector: deps[i].Selector}
+		for j := 0; j < benchLinksPerKey; j++ {
+			if err := backend.AddLink(parent.ID, l, fmt.Sprintf("target-%d-%d", i, j)); err != nil {
+				b.Fatalf("AddLink: %v", err)
+			}
+		}
+	}
+	return cm, deps, input, dgst, output
+}
+
+// BenchmarkQueryCold issues one Query per distinct parent key, so every call
+// misses the excerpt index and falls through to backend.WalkLinks - this is
+// the cost an unbounded link fanout pays without excerpts at all.
+func BenchmarkQueryCold(b *testing.B) {
+	cm, deps, input, dgst, output := buildBenchFixture(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dep := deps[i%len(deps)]
+		cm.excerpts.invalidate(cm.getID(dep.CacheKey.CacheKey))
+		if _, err := cm.Query([]CacheKeyWithSelector{dep}, input, dgst, output); err != nil {
+			b.Fatalf("Query: %v", err)
+		}
+	}
+}
+
+// BenchmarkQueryWarm repeatedly queries the same already-warmed parent keys,
+// so every call hits the excerpt index instead of re-walking the backend's
+// link graph - this is the saving the excerpt index exists to provide.
+func BenchmarkQueryWarm(b *testing.B) {
+	cm, deps, input, dgst, output := buildBenchFixture(b)
+	for _, dep := range deps {
+		if _, err := cm.Query([]CacheKeyWithSelector{dep}, input, dgst, output); err != nil {
+			b.Fatalf("warm Query: %v", err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dep := deps[i%len(deps)]
+		if _, err := cm.Query([]CacheKeyWithSelector{dep}, input, dgst, output); err != nil {
+			b.Fatalf("Query: %v", err)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package solver
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/identity"
+	"github.com/moby/buildkit/util/bklog"
+)
+
+// EvictionPolicy selects the replacement strategy used by a size-bounded
+// in-memory cache manager once its configured limits are exceeded.
+type EvictionPolicy string
+
+const (
+	// EvictionPolicyLRU evicts the least-recently-used entry first.
+	EvictionPolicyLRU EvictionPolicy = "lru"
+	// EvictionPolicyLFU evicts the least-frequently-used entry first.
+	EvictionPolicyLFU EvictionPolicy = "lfu"
+)
+
+// InMemoryCacheOptions configures a size-bounded in-memory cache manager
+// created via NewInMemoryCacheManagerWithOptions.
+type InMemoryCacheOptions struct {
+	// MaxSize is the maximum cumulative size of tracked entries, expressed
+	// as a human readable byte value (eg. "64MB"). Zero disables the byte
+	// budget.
+	MaxSize string
+	// MaxEntries is the maximum number of cache keys retained. Zero
+	// disables the entry-count budget.
+	MaxEntries int
+	// Policy selects which entry is evicted first once a budget is
+	// exceeded. Defaults to EvictionPolicyLRU.
+	Policy EvictionPolicy
+	// OnEvict, when set, is called synchronously with the id of every
+	// entry evicted so callers can persist it elsewhere before it is
+	// dropped from memory.
+	OnEvict func(id string)
+	// Observer, when set, receives activity notifications for the created
+	// cache manager. See CacheManagerObserver.
+	Observer CacheManagerObserver
+	// ExcerptCapacity overrides the entry budget of the RAM-resident
+	// link/result excerpt index (see cacheexcerpt.go). Zero disables the
+	// budget, keeping every excerpt ever populated, matching MaxSize and
+	// MaxEntries above and WithExcerptCapacity's own zero value.
+	ExcerptCapacity int
+}
+
+// Toucher is implemented by CacheKeyStorage backends that want recency
+// information forwarded to them so they can make their own eviction
+// decisions independently of the evictor below.
+type Toucher interface {
+	Touch(id string) error
+}
+
+// Deleter is implemented by CacheKeyStorage backends that support dropping a
+// cache key's metadata (and any links recorded under it) outright. The
+// size-bounded eviction policy consults it when an entry is evicted from
+// memory: CacheKeyStorage.Release only ever frees a *result*, so without a
+// Deleter a bounded cache manager only bounds its own bookkeeping while the
+// backend keeps every key and link forever — exactly the growth this
+// feature exists to fix.
+type Deleter interface {
+	Delete(id string) error
+}
+
+// Sizer is implemented by CacheKeyStorage or CacheResultStorage backends
+// that can report an approximate size, in bytes, for a given id. It is used
+// to enforce InMemoryCacheOptions.MaxSize.
+type Sizer interface {
+	Size(id string) (int64, error)
+}
+
+// NewInMemoryCacheManagerWithOptions creates an in-memory cache manager
+// whose key and link metadata is bounded by size and/or entry count,
+// evicting the least valuable entries (per opts.Policy) once a configured
+// limit is exceeded. Unlike NewInMemoryCacheManager, it is intended for
+// long-running daemons that would otherwise accumulate cache metadata
+// without bound.
+func NewInMemoryCacheManagerWithOptions(opts InMemoryCacheOptions) (CacheManager, error) {
+	maxBytes, err := parseCacheSize(opts.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := opts.Policy
+	if policy == "" {
+		policy = EvictionPolicyLRU
+	}
+
+	observer := opts.Observer
+	if observer == nil {
+		observer = noopObserver{}
+	}
+
+	ctx := context.TODO()
+	cm := &cacheManager{
+		id:       identity.NewID(),
+		backend:  NewInMemoryCacheStorage(),
+		results:  NewInMemoryResultStorage(),
+		observer: observer,
+		excerpts: newExcerptStore(opts.ExcerptCapacity),
+	}
+	cm.evictor = newCacheEvictor(policy, maxBytes, opts.MaxEntries, func(reason, id string) {
+		if opts.OnEvict != nil {
+			opts.OnEvict(id)
+		}
+		if d, ok := cm.backend.(Deleter); ok {
+			if err := d.Delete(id); err != nil {
+				bklog.G(ctx).Debugf("cache manager %s: evict %s: %v", cm.id, id, err)
+			}
+		}
+		cm.excerpts.invalidate(id)
+		cm.excerpts.rootInvalidate(id)
+		observer.OnEvict(reason, id)
+	})
+
+	if err := cm.ReleaseUnreferenced(ctx); err != nil {
+		bklog.G(ctx).Errorf("failed to release unreferenced cache metadata: %+v", err)
+	}
+
+	return cm, nil
+}
+
+func parseCacheSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := units.RAMInBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cache size %q: %w", s, err)
+	}
+	return v, nil
+}
+
+// cacheEvictor tracks recency/frequency and cumulative size for cache keys
+// tracked by a cacheManager and decides what to drop once a configured
+// budget is exceeded. A cache key is only ever evicted while refs for it is
+// zero, i.e. while it has no outgoing link to a target that still exists —
+// evicting it would otherwise delete the backend link entry Query/hasLink
+// need to reach that still-live target through it, orphaning a cache hit.
+type cacheEvictor struct {
+	mu         sync.Mutex
+	policy     EvictionPolicy
+	maxBytes   int64
+	maxEntries int
+	onEvict    func(reason, id string)
+
+	ls    *list.List // front = most recently used
+	elems map[string]*list.Element
+	size  map[string]int64
+	freq  map[string]int
+
+	refs     map[string]int                 // parentID -> number of distinct live targets it links to
+	outgoing map[string]map[string]struct{} // parentID -> set of targetIDs it links to
+	incoming map[string]map[string]struct{} // targetID -> set of parentIDs that link to it
+
+	total int64
+}
+
+func newCacheEvictor(policy EvictionPolicy, maxBytes int64, maxEntries int, onEvict func(reason, id string)) *cacheEvictor {
+	return &cacheEvictor{
+		policy:     policy,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		onEvict:    onEvict,
+		ls:         list.New(),
+		elems:      map[string]*list.Element{},
+		size:       map[string]int64{},
+		freq:       map[string]int{},
+		refs:       map[string]int{},
+		outgoing:   map[string]map[string]struct{}{},
+		incoming:   map[string]map[string]struct{}{},
+	}
+}
+
+// touch bumps the recency/frequency of id. It is safe to call for ids the
+// evictor has not seen yet; such calls are ignored until insert is called.
+func (e *cacheEvictor) touch(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	el, ok := e.elems[id]
+	if !ok {
+		return
+	}
+	e.freq[id]++
+	if e.policy == EvictionPolicyLRU {
+		e.ls.MoveToFront(el)
+	}
+}
+
+// link records that parentID has a backend link pointing at targetID,
+// protecting parentID from eviction (refs[parentID] > 0) for as long as
+// targetID hasn't itself been dropped — evicting parentID first would
+// delete the backend link entry that makes targetID reachable via
+// walkLinks/hasLink, even though targetID itself would still exist.
+func (e *cacheEvictor) link(parentID, targetID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out, ok := e.outgoing[parentID]
+	if !ok {
+		out = map[string]struct{}{}
+		e.outgoing[parentID] = out
+	}
+	if _, ok := out[targetID]; ok {
+		return
+	}
+	out[targetID] = struct{}{}
+	e.refs[parentID]++
+
+	in, ok := e.incoming[targetID]
+	if !ok {
+		in = map[string]struct{}{}
+		e.incoming[targetID] = in
+	}
+	in[parentID] = struct{}{}
+}
+
+// insert registers id as sz bytes large, resets its recency to most-recently
+// used, and evicts entries (other than id itself) until the configured
+// budget is satisfied again.
+func (e *cacheEvictor) insert(id string, sz int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if el, ok := e.elems[id]; ok {
+		e.total += sz - e.size[id]
+		e.size[id] = sz
+		e.freq[id]++
+		if e.policy == EvictionPolicyLRU {
+			e.ls.MoveToFront(el)
+		}
+	} else {
+		el := e.ls.PushFront(id)
+		e.elems[id] = el
+		e.size[id] = sz
+		e.freq[id] = 1
+		e.total += sz
+	}
+
+	e.evictLocked(id)
+}
+
+// evictLocked drops entries (other than keep) until both the byte and
+// entry-count budgets are satisfied, or until no evictable entry remains.
+// Callers must hold e.mu.
+func (e *cacheEvictor) evictLocked(keep string) {
+	for e.overBudgetLocked() {
+		id, ok := e.victimLocked(keep)
+		if !ok {
+			return
+		}
+		e.removeLocked(id)
+		if e.onEvict != nil {
+			e.onEvict(string(e.policy), id)
+		}
+	}
+}
+
+func (e *cacheEvictor) overBudgetLocked() bool {
+	if e.maxEntries > 0 && e.ls.Len() > e.maxEntries {
+		return true
+	}
+	if e.maxBytes > 0 && e.total > e.maxBytes {
+		return true
+	}
+	return false
+}
+
+// victimLocked picks the next entry to evict, skipping keep and any entry
+// still referenced by a live link. Callers must hold e.mu.
+func (e *cacheEvictor) victimLocked(keep string) (string, bool) {
+	if e.policy == EvictionPolicyLFU {
+		var victim string
+		best := -1
+		for id := range e.elems {
+			if id == keep || e.refs[id] > 0 {
+				continue
+			}
+			if best == -1 || e.freq[id] < best {
+				best = e.freq[id]
+				victim = id
+			}
+		}
+		return victim, best != -1
+	}
+
+	for el := e.ls.Back(); el != nil; el = el.Prev() {
+		id := el.Value.(string)
+		if id == keep || e.refs[id] > 0 {
+			continue
+		}
+		return id, true
+	}
+	return "", false
+}
+
+// forget drops all bookkeeping for id, for use when id was released directly
+// (eg. ReleaseUnreferenced, an orphaned Records entry) rather than dropped by
+// the eviction policy. Unlike evictLocked's path, this never calls onEvict:
+// the backend state is already gone by the time the caller asks us to forget
+// it, so there's nothing left to delete.
+func (e *cacheEvictor) forget(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.removeLocked(id)
+}
+
+// removeLocked drops all bookkeeping for id, including the link refcounts
+// it holds against/from other still-tracked ids. Callers must hold e.mu.
+func (e *cacheEvictor) removeLocked(id string) {
+	if el, ok := e.elems[id]; ok {
+		e.ls.Remove(el)
+	}
+	e.total -= e.size[id]
+	delete(e.elems, id)
+	delete(e.size, id)
+	delete(e.freq, id)
+
+	// id is a target: every parent linking to it loses that reference.
+	for parentID := range e.incoming[id] {
+		if out, ok := e.outgoing[parentID]; ok {
+			delete(out, id)
+			if len(out) == 0 {
+				delete(e.outgoing, parentID)
+			}
+		}
+		if e.refs[parentID] > 0 {
+			e.refs[parentID]--
+			if e.refs[parentID] == 0 {
+				delete(e.refs, parentID)
+			}
+		}
+	}
+	delete(e.incoming, id)
+
+	// id is a parent: every target it links to loses that incoming edge.
+	for targetID := range e.outgoing[id] {
+		if in, ok := e.incoming[targetID]; ok {
+			delete(in, id)
+			if len(in) == 0 {
+				delete(e.incoming, targetID)
+			}
+		}
+	}
+	delete(e.outgoing, id)
+	delete(e.refs, id)
+}
@@ -0,0 +1,158 @@
+package solver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+// fakeResult is the minimal Result implementation needed to exercise Save
+// without pulling in a real executor-backed result.
+type fakeResult struct{ id string }
+
+func (r fakeResult) ID() string { return r.id }
+
+func (r fakeResult) Release(context.Context) error { return nil }
+
+func (r fakeResult) Sys() interface{} { return nil }
+
+// TestCacheEvictorRefsProtectParent checks that link() protects the parent
+// side of a backend link from eviction, not the target: evicting the parent
+// while its child is still live would delete the backend link entry that
+// makes the child reachable via walkLinks/hasLink, orphaning a live result.
+func TestCacheEvictorRefsProtectParent(t *testing.T) {
+	var evicted []string
+	e := newCacheEvictor(EvictionPolicyLRU, 0, 2, func(reason, id string) {
+		evicted = append(evicted, id)
+	})
+
+	e.insert("parent", 1)
+	e.link("parent", "child")
+	e.insert("child", 1)
+	e.insert("other", 1)
+
+	if len(evicted) != 1 || evicted[0] != "child" {
+		t.Fatalf("expected the unreferenced child to be evicted instead of its linking parent, evicted=%v", evicted)
+	}
+}
+
+func TestCacheEvictorForgetDropsRefs(t *testing.T) {
+	var evicted []string
+	e := newCacheEvictor(EvictionPolicyLRU, 0, 2, func(reason, id string) {
+		evicted = append(evicted, id)
+	})
+
+	e.insert("parent", 1)
+	e.link("parent", "child")
+	e.insert("child", 1)
+
+	e.forget("parent")
+
+	e.insert("other", 1)
+	e.insert("another", 1)
+
+	for _, id := range evicted {
+		if id == "parent" {
+			t.Fatalf("forget should not itself trigger onEvict, got %v", evicted)
+		}
+	}
+	if _, ok := e.refs["parent"]; ok {
+		t.Fatalf("expected parent's refs to be gone after forget")
+	}
+	if _, ok := e.elems["parent"]; ok {
+		t.Fatalf("expected parent to be fully forgotten")
+	}
+}
+
+func TestCacheEvictorRemoveLockedClearsTargetRefs(t *testing.T) {
+	e := newCacheEvictor(EvictionPolicyLRU, 0, 0, nil)
+
+	e.insert("parent", 1)
+	e.link("parent", "child")
+	e.insert("child", 1)
+
+	if e.refs["parent"] != 1 {
+		t.Fatalf("expected parent to have 1 ref, got %d", e.refs["parent"])
+	}
+
+	e.forget("child")
+
+	if _, ok := e.refs["parent"]; ok {
+		t.Fatalf("expected parent's ref to child to be dropped once child is forgotten, refs=%v", e.refs)
+	}
+}
+
+func TestCacheEvictorLFUEvictsLeastUsed(t *testing.T) {
+	var evicted []string
+	e := newCacheEvictor(EvictionPolicyLFU, 0, 2, func(reason, id string) {
+		evicted = append(evicted, id)
+	})
+
+	e.insert("a", 1)
+	e.touch("a")
+	e.touch("a")
+	e.insert("b", 1)
+	e.insert("c", 1)
+
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Fatalf("expected least-frequently-used entry b to be evicted, got %v", evicted)
+	}
+}
+
+// TestNewInMemoryCacheManagerWithOptionsEvictsThroughBackend exercises
+// NewInMemoryCacheManagerWithOptions end to end: saving past MaxEntries must
+// actually delete the evicted key from the backend (not just forget it in
+// the evictor's own bookkeeping), and a root key queried after its eviction
+// must be reported as gone rather than served stale out of the excerpt
+// index's root cache - the exact gap that let the missing rootInvalidate
+// call in the eviction callback go unnoticed.
+func TestNewInMemoryCacheManagerWithOptionsEvictsThroughBackend(t *testing.T) {
+	var evicted []string
+	cm, err := NewInMemoryCacheManagerWithOptions(InMemoryCacheOptions{
+		MaxEntries: 1,
+		OnEvict:    func(id string) { evicted = append(evicted, id) },
+	})
+	if err != nil {
+		t.Fatalf("NewInMemoryCacheManagerWithOptions: %v", err)
+	}
+	c := cm.(*cacheManager)
+
+	input, output := Index(0), Index(0)
+	firstDgst := digest.FromString("first-root")
+	secondDgst := digest.FromString("second-root")
+
+	firstKey := c.newRootKey(firstDgst, output)
+	if _, err := c.Save(firstKey, fakeResult{id: "res-first"}, time.Now()); err != nil {
+		t.Fatalf("Save first: %v", err)
+	}
+
+	// Query the first root key while it still exists, so the excerpt
+	// index's root cache holds an entry for it that eviction must drop.
+	if cks, err := c.Query(nil, input, firstDgst, output); err != nil || len(cks) != 1 {
+		t.Fatalf("expected the first root key to be found before eviction, got %v, %v", cks, err)
+	}
+
+	// Saving a second, distinct root key pushes the manager past its
+	// MaxEntries budget of 1, evicting the first.
+	secondKey := c.newRootKey(secondDgst, output)
+	if _, err := c.Save(secondKey, fakeResult{id: "res-second"}, time.Now()); err != nil {
+		t.Fatalf("Save second: %v", err)
+	}
+
+	if len(evicted) != 1 || evicted[0] != firstKey.ID {
+		t.Fatalf("expected the first root key to be evicted, got %v", evicted)
+	}
+	if c.backend.Exists(firstKey.ID) {
+		t.Fatalf("expected eviction to delete %s from the backend via Deleter", firstKey.ID)
+	}
+
+	cks, err := c.Query(nil, input, firstDgst, output)
+	if err != nil {
+		t.Fatalf("Query evicted root: %v", err)
+	}
+	if len(cks) != 0 {
+		t.Fatalf("expected the evicted root key to be reported as gone, got %v", cks)
+	}
+}
@@ -0,0 +1,52 @@
+package solver
+
+import "time"
+
+// CacheManagerObserver receives lightweight notifications about cache
+// manager activity. It lets callers wire up metrics (see solver/metrics for
+// a Prometheus adapter) without the cache manager itself depending on any
+// particular metrics backend.
+type CacheManagerObserver interface {
+	// OnQuery is called after every Query, reporting whether it returned at
+	// least one cache key, how many dependencies were queried against, and
+	// how long the query took.
+	OnQuery(hit bool, deps int, duration time.Duration)
+	// OnLoad is called after every Load/LoadWithParents, reporting whether a
+	// result was found, its size in bytes if known (0 otherwise), and how
+	// long loading took.
+	OnLoad(hit bool, bytes int64, duration time.Duration)
+	// OnSave is called after every successful Save with the size, in bytes,
+	// of the saved result, if known (0 otherwise).
+	OnSave(bytes int64)
+	// OnEvict is called whenever a cache entry is dropped from memory, with
+	// a short reason ("lru", "lfu", "unreferenced") and the id of the entry.
+	OnEvict(reason string, id string)
+}
+
+type noopObserver struct{}
+
+func (noopObserver) OnQuery(bool, int, time.Duration)  {}
+func (noopObserver) OnLoad(bool, int64, time.Duration) {}
+func (noopObserver) OnSave(int64)                      {}
+func (noopObserver) OnEvict(string, string)            {}
+
+// CacheManagerOpt configures optional behavior of a CacheManager created via
+// NewCacheManager.
+type CacheManagerOpt func(*cacheManager)
+
+// WithObserver attaches an observer that receives cache manager activity
+// notifications, for wiring up metrics.
+func WithObserver(o CacheManagerObserver) CacheManagerOpt {
+	return func(cm *cacheManager) {
+		cm.observer = o
+	}
+}
+
+// WithExcerptCapacity overrides the default entry budget of the cache
+// manager's RAM-resident link/result excerpt index (see cacheexcerpt.go).
+// A capacity of 0 disables the budget, keeping every entry ever populated.
+func WithExcerptCapacity(capacity int) CacheManagerOpt {
+	return func(cm *cacheManager) {
+		cm.excerpts = newExcerptStore(capacity)
+	}
+}
@@ -0,0 +1,33 @@
+package solver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+)
+
+type recordingObserver struct {
+	queries int
+}
+
+func (o *recordingObserver) OnQuery(hit bool, deps int, duration time.Duration) { o.queries++ }
+func (recordingObserver) OnLoad(bool, int64, time.Duration)                     {}
+func (recordingObserver) OnSave(int64)                                          {}
+func (recordingObserver) OnEvict(string, string)                                {}
+
+func TestRecordsNotifiesObserver(t *testing.T) {
+	backend := newBenchBackend()
+	obs := &recordingObserver{}
+	cm := NewCacheManager(context.Background(), "obs-test", backend, benchResultStorage{}, WithObserver(obs)).(*cacheManager)
+
+	k := cm.newKeyWithID("key", digest.FromString("obs"), Index(0))
+	if _, err := cm.Records(context.Background(), k); err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+
+	if obs.queries != 1 {
+		t.Fatalf("expected Records to notify the observer exactly once, got %d", obs.queries)
+	}
+}
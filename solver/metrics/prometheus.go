@@ -0,0 +1,86 @@
+// Package metrics provides a default solver.CacheManagerObserver that
+// exposes cache manager activity as Prometheus metrics, so operators running
+// a long-lived buildkitd can monitor cache effectiveness instead of having
+// to grep trace logs.
+package metrics
+
+import (
+	"time"
+
+	"github.com/moby/buildkit/solver"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// NewPrometheusObserver creates a solver.CacheManagerObserver that registers
+// its collectors with reg. Pass prometheus.DefaultRegisterer to use the
+// default global registry.
+func NewPrometheusObserver(reg prometheus.Registerer) solver.CacheManagerObserver {
+	factory := promauto.With(reg)
+
+	return &prometheusObserver{
+		queryTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "buildkit_cache_query_total",
+			Help: "Number of cache manager queries, by result.",
+		}, []string{"result"}),
+		queryDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "buildkit_cache_query_duration_seconds",
+			Help:    "Latency of cache manager queries.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		loadBytesTotal: factory.NewCounter(prometheus.CounterOpts{
+			Name: "buildkit_cache_load_bytes_total",
+			Help: "Cumulative size of results loaded from the cache manager.",
+		}),
+		loadTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "buildkit_cache_load_total",
+			Help: "Number of cache manager loads, by result.",
+		}, []string{"result"}),
+		saveBytesTotal: factory.NewCounter(prometheus.CounterOpts{
+			Name: "buildkit_cache_save_bytes_total",
+			Help: "Cumulative size of results saved to the cache manager.",
+		}),
+		evictTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "buildkit_cache_evict_total",
+			Help: "Number of cache entries evicted from memory, by reason.",
+		}, []string{"reason"}),
+	}
+}
+
+type prometheusObserver struct {
+	queryTotal     *prometheus.CounterVec
+	queryDuration  prometheus.Histogram
+	loadBytesTotal prometheus.Counter
+	loadTotal      *prometheus.CounterVec
+	saveBytesTotal prometheus.Counter
+	evictTotal     *prometheus.CounterVec
+}
+
+func (o *prometheusObserver) OnQuery(hit bool, _ int, duration time.Duration) {
+	o.queryTotal.WithLabelValues(resultLabel(hit)).Inc()
+	o.queryDuration.Observe(duration.Seconds())
+}
+
+func (o *prometheusObserver) OnLoad(hit bool, bytes int64, _ time.Duration) {
+	o.loadTotal.WithLabelValues(resultLabel(hit)).Inc()
+	if bytes > 0 {
+		o.loadBytesTotal.Add(float64(bytes))
+	}
+}
+
+func (o *prometheusObserver) OnSave(bytes int64) {
+	if bytes > 0 {
+		o.saveBytesTotal.Add(float64(bytes))
+	}
+}
+
+func (o *prometheusObserver) OnEvict(reason, _ string) {
+	o.evictTotal.WithLabelValues(reason).Inc()
+}
+
+func resultLabel(hit bool) string {
+	if hit {
+		return "hit"
+	}
+	return "miss"
+}